@@ -0,0 +1,48 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import "context"
+
+// CtxLogger is an optional interface that a Logger may implement to accept
+// a context.Context directly. Packages like zap/ctxlog use it to pass the
+// caller's context all the way down to each sub-logger of a TeeLogger,
+// rather than pre-flattening it into Fields once at the top.
+//
+// A Logger that doesn't implement CtxLogger is still perfectly usable with
+// context-aware wrappers: multiLogger falls back to the ordinary Log method
+// for any sub-logger that doesn't implement it.
+type CtxLogger interface {
+	LogCtx(ctx context.Context, lvl Level, msg string, fields ...Field)
+}
+
+// LogCtx implements CtxLogger. Each sub-logger that implements CtxLogger
+// itself receives ctx directly; the rest just get the Fields already
+// gathered by the caller.
+func (ml multiLogger) LogCtx(ctx context.Context, lvl Level, msg string, fields ...Field) {
+	for _, log := range ml {
+		if cl, ok := log.(CtxLogger); ok {
+			cl.LogCtx(ctx, lvl, msg, fields...)
+			continue
+		}
+		log.Log(lvl, msg, fields...)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package ctxlog wraps a zap.Logger so that well-known values carried on a
+// context.Context -- request IDs, tenant IDs, an active OpenTelemetry span,
+// and so on -- are attached to every log record as Fields automatically.
+package ctxlog
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Extractor pulls a single Field out of a context.Context. It reports
+// ok == false when the context carries no value for that key, in which case
+// the field is omitted rather than logged empty.
+type Extractor func(ctx context.Context) (zap.Field, bool)
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{}
+	// extractorOrder records registration order so extractFields produces
+	// the same field order on every call; ranging over extractors directly
+	// would vary with Go's randomized map iteration.
+	extractorOrder []string
+)
+
+// RegisterContextExtractor registers fn to contribute a Field, under key,
+// to every Ctx-suffixed call made through this package. Registering under a
+// key that's already in use replaces the previous extractor without
+// changing its position in that order. It is typically called once, from an
+// init function.
+func RegisterContextExtractor(key string, fn Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	if _, ok := extractors[key]; !ok {
+		extractorOrder = append(extractorOrder, key)
+	}
+	extractors[key] = fn
+}
+
+func extractFields(ctx context.Context) []zap.Field {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	fields := make([]zap.Field, 0, len(extractorOrder)+2)
+	for _, key := range extractorOrder {
+		if f, ok := extractors[key](ctx); ok {
+			fields = append(fields, f)
+		}
+	}
+	if sc, ok := spanFromContext(ctx); ok {
+		fields = append(fields, zap.String("trace_id", sc.TraceID()), zap.String("span_id", sc.SpanID()))
+	}
+	return fields
+}
+
+// Logger wraps a zap.Logger, threading a context.Context through each call
+// so the registered extractors and any span in the context can attach
+// Fields without the caller having to do it by hand.
+//
+// The zero value is not usable; construct one with New.
+type Logger struct {
+	base           zap.Logger
+	spanEventLevel zap.Level
+}
+
+// New returns a context-aware Logger that delegates to base. base may
+// itself be a zap.TeeLogger: because Logger resolves context fields once,
+// up front, and hands the enriched record to base's LogCtx (when base
+// implements zap.CtxLogger, as a TeeLogger does) or else to its Log, every
+// sub-logger of a tee receives the same enriched record.
+func New(base zap.Logger) *Logger {
+	return &Logger{base: base, spanEventLevel: zap.ErrorLevel}
+}
+
+// SetSpanEventLevel controls the minimum level at which log records are
+// also recorded as events on any SpanRecorder found in the context. The
+// default is zap.ErrorLevel.
+func (l *Logger) SetSpanEventLevel(lvl zap.Level) {
+	l.spanEventLevel = lvl
+}
+
+// Ctx returns a child Logger with ctx's fields already resolved and bound,
+// suitable for passing to code that doesn't carry a context itself.
+func (l *Logger) Ctx(ctx context.Context) zap.Logger {
+	return l.base.With(extractFields(ctx)...)
+}
+
+// allFields resolves ctx's fields and appends fields, recording a span event
+// on the way if ctx carries a SpanRecorder and lvl clears spanEventLevel.
+// Every entry point into this package -- the DebugCtx family, PanicCtx,
+// FatalCtx, and ctxWriter's methods alike -- funnels through here so span
+// recording and field resolution behave the same no matter which one a
+// caller used.
+func (l *Logger) allFields(ctx context.Context, lvl Level, msg string, fields []zap.Field) []zap.Field {
+	all := append(extractFields(ctx), fields...)
+	if rec, ok := spanRecorderFromContext(ctx); ok && lvl >= l.spanEventLevel {
+		rec.AddEvent(msg, all)
+	}
+	return all
+}
+
+func (l *Logger) logCtx(ctx context.Context, lvl Level, msg string, fields []zap.Field) {
+	all := l.allFields(ctx, lvl, msg, fields)
+	if cl, ok := l.base.(zap.CtxLogger); ok {
+		cl.LogCtx(ctx, zap.Level(lvl), msg, all...)
+		return
+	}
+	l.base.Log(zap.Level(lvl), msg, all...)
+}
+
+// Level mirrors zap.Level so callers of this package don't need to import
+// zap just to name a level when calling Check/CheckCtx.
+type Level = zap.Level
+
+// callerField reports the file:line of the frame skip levels above its own
+// -- the zap.AddCallerSkip(1) equivalent this package needs so the caller
+// field names the application's call site rather than one of ctxlog's own
+// wrapper frames. The right skip depends on how many of those wrapper
+// frames sit between the application and this function; see withCaller's
+// call sites for the two depths this package uses.
+func callerField(skip int) (zap.Field, bool) {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return zap.Field{}, false
+	}
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		file = file[i+1:]
+	}
+	return zap.String("caller", file+":"+strconv.Itoa(line)), true
+}
+
+// withCaller appends a caller field identifying the user's call site. skip
+// is handed to callerField unchanged, so it counts frames above callerField
+// itself: withCaller is one of them and its caller another.
+func withCaller(fields []zap.Field, skip int) []zap.Field {
+	if cf, ok := callerField(skip); ok {
+		return append(fields, cf)
+	}
+	return fields
+}
+
+// DebugCtx logs msg at zap.DebugLevel, with ctx's fields prepended.
+func (l *Logger) DebugCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logCtx(ctx, zap.DebugLevel, msg, withCaller(fields, 3))
+}
+
+// InfoCtx logs msg at zap.InfoLevel, with ctx's fields prepended.
+func (l *Logger) InfoCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logCtx(ctx, zap.InfoLevel, msg, withCaller(fields, 3))
+}
+
+// WarnCtx logs msg at zap.WarnLevel, with ctx's fields prepended.
+func (l *Logger) WarnCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logCtx(ctx, zap.WarnLevel, msg, withCaller(fields, 3))
+}
+
+// ErrorCtx logs msg at zap.ErrorLevel, with ctx's fields prepended.
+func (l *Logger) ErrorCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	l.logCtx(ctx, zap.ErrorLevel, msg, withCaller(fields, 3))
+}
+
+// PanicCtx resolves ctx's fields before any sub-logger runs, then panics
+// exactly as zap.Logger.Panic would, so termination still happens only
+// after every sink has received the enriched record.
+func (l *Logger) PanicCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	all := l.allFields(ctx, zap.PanicLevel, msg, withCaller(fields, 3))
+	l.base.Panic(msg, all...)
+}
+
+// FatalCtx resolves ctx's fields before any sub-logger runs, then exits
+// exactly as zap.Logger.Fatal would.
+func (l *Logger) FatalCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	all := l.allFields(ctx, zap.FatalLevel, msg, withCaller(fields, 3))
+	l.base.Fatal(msg, all...)
+}
+
+// CheckCtx is the context-aware counterpart of zap.Logger.Check: it returns
+// nil if lvl is disabled, so a disabled level never pays for extractFields'
+// work. Context fields are bound lazily -- only once Write is actually
+// called on the returned CheckedMessage.
+func (l *Logger) CheckCtx(ctx context.Context, lvl zap.Level, msg string) *zap.CheckedMessage {
+	if cm := l.base.Check(lvl, msg); cm == nil {
+		return nil
+	}
+	return zap.NewCheckedMessage(ctxWriter{l: l, ctx: ctx}, lvl, msg)
+}
+
+// ctxWriter adapts Logger to the zap.Logger interface so it can back a
+// CheckedMessage returned by CheckCtx, binding ctx's fields only when one of
+// its methods is actually called.
+type ctxWriter struct {
+	l   *Logger
+	ctx context.Context
+}
+
+func (w ctxWriter) with(fields []zap.Field) []zap.Field {
+	return append(extractFields(w.ctx), fields...)
+}
+
+// These methods are reached through a CheckedMessage returned by CheckCtx,
+// one frame below the application's call to Write -- hence skip == 4
+// rather than the 3 DebugCtx and friends use directly. Each one funnels
+// through w.l.logCtx/allFields, same as the DebugCtx family and
+// PanicCtx/FatalCtx, so span-event recording and CtxLogger propagation
+// happen identically regardless of which idiom the caller used.
+
+func (w ctxWriter) Log(lvl zap.Level, msg string, fields ...zap.Field) {
+	w.l.logCtx(w.ctx, lvl, msg, withCaller(fields, 4))
+}
+
+func (w ctxWriter) Debug(msg string, fields ...zap.Field) {
+	w.l.logCtx(w.ctx, zap.DebugLevel, msg, withCaller(fields, 4))
+}
+func (w ctxWriter) Info(msg string, fields ...zap.Field) {
+	w.l.logCtx(w.ctx, zap.InfoLevel, msg, withCaller(fields, 4))
+}
+func (w ctxWriter) Warn(msg string, fields ...zap.Field) {
+	w.l.logCtx(w.ctx, zap.WarnLevel, msg, withCaller(fields, 4))
+}
+func (w ctxWriter) Error(msg string, fields ...zap.Field) {
+	w.l.logCtx(w.ctx, zap.ErrorLevel, msg, withCaller(fields, 4))
+}
+func (w ctxWriter) DFatal(msg string, fields ...zap.Field) {
+	all := w.l.allFields(w.ctx, zap.ErrorLevel, msg, withCaller(fields, 4))
+	w.l.base.DFatal(msg, all...)
+}
+func (w ctxWriter) Panic(msg string, fields ...zap.Field) {
+	all := w.l.allFields(w.ctx, zap.PanicLevel, msg, withCaller(fields, 4))
+	w.l.base.Panic(msg, all...)
+}
+func (w ctxWriter) Fatal(msg string, fields ...zap.Field) {
+	all := w.l.allFields(w.ctx, zap.FatalLevel, msg, withCaller(fields, 4))
+	w.l.base.Fatal(msg, all...)
+}
+func (w ctxWriter) With(fields ...zap.Field) zap.Logger { return w.l.base.With(w.with(fields)...) }
+func (w ctxWriter) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
+	return w.l.base.Check(lvl, msg)
+}
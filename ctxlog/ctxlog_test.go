@@ -0,0 +1,266 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ctxlog
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeLogger is a zap.Logger test double that records every call it
+// receives, guarded by a mutex since some tests exercise it via
+// CheckCtx(...).Write(...), CtxLogger, and Ctx all at once.
+type fakeLogger struct {
+	mu    sync.Mutex
+	calls []fakeCall
+
+	// asCtxLogger is only carried over to the loggers With returns; it
+	// doesn't change how calls reach record. ctxLoggerFake below is the
+	// double that implements zap.CtxLogger.
+	asCtxLogger bool
+	ctxCalls    []context.Context
+
+	extra []zap.Field
+}
+
+type fakeCall struct {
+	lvl    zap.Level
+	msg    string
+	fields []zap.Field
+}
+
+func (f *fakeLogger) record(lvl zap.Level, msg string, fields []zap.Field) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all := append(append([]zap.Field(nil), f.extra...), fields...)
+	f.calls = append(f.calls, fakeCall{lvl: lvl, msg: msg, fields: all})
+}
+
+func (f *fakeLogger) Log(lvl zap.Level, msg string, fields ...zap.Field) { f.record(lvl, msg, fields) }
+func (f *fakeLogger) Debug(msg string, fields ...zap.Field)              { f.record(zap.DebugLevel, msg, fields) }
+func (f *fakeLogger) Info(msg string, fields ...zap.Field)               { f.record(zap.InfoLevel, msg, fields) }
+func (f *fakeLogger) Warn(msg string, fields ...zap.Field)               { f.record(zap.WarnLevel, msg, fields) }
+func (f *fakeLogger) Error(msg string, fields ...zap.Field)              { f.record(zap.ErrorLevel, msg, fields) }
+func (f *fakeLogger) DFatal(msg string, fields ...zap.Field)             { f.record(zap.ErrorLevel, msg, fields) }
+
+func (f *fakeLogger) Panic(msg string, fields ...zap.Field) {
+	f.record(zap.PanicLevel, msg, fields)
+	panic(msg)
+}
+
+// Fatal records the call rather than actually exiting, since a unit test
+// can't survive the real zap.Logger.Fatal contract; FatalCtx/ctxWriter.Fatal
+// are still exercised up to the point where they'd hand off to it.
+func (f *fakeLogger) Fatal(msg string, fields ...zap.Field) { f.record(zap.FatalLevel, msg, fields) }
+
+func (f *fakeLogger) With(fields ...zap.Field) zap.Logger {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &fakeLogger{extra: append(append([]zap.Field(nil), f.extra...), fields...), asCtxLogger: f.asCtxLogger}
+}
+
+func (f *fakeLogger) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
+	return zap.NewCheckedMessage(f, lvl, msg)
+}
+
+func (f *fakeLogger) snapshot() []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeCall(nil), f.calls...)
+}
+
+// ctxLoggerFake additionally implements zap.CtxLogger, so tests can confirm
+// ctxlog prefers LogCtx over Log when the base Logger supports it.
+type ctxLoggerFake struct {
+	fakeLogger
+}
+
+func (f *ctxLoggerFake) LogCtx(ctx context.Context, lvl zap.Level, msg string, fields ...zap.Field) {
+	f.mu.Lock()
+	f.ctxCalls = append(f.ctxCalls, ctx)
+	f.mu.Unlock()
+	f.record(lvl, msg, fields)
+}
+
+// fakeSpan implements SpanRecorder so tests can assert AddEvent is called
+// consistently across every Ctx-suffixed entry point, at or above the
+// configured SetSpanEventLevel.
+type fakeSpan struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *fakeSpan) TraceID() string { return "trace-1" }
+func (s *fakeSpan) SpanID() string  { return "span-1" }
+
+func (s *fakeSpan) AddEvent(name string, fields []zap.Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, name)
+}
+
+func (s *fakeSpan) snapshot() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.events...)
+}
+
+// keyCapturingKV is a minimal zap.KeyValue test double. zap.Field has no
+// exported accessors, so tests recover a field's key by replaying it
+// through AddTo.
+type keyCapturingKV struct {
+	key string
+}
+
+func (kv *keyCapturingKV) AddBool(key string, val bool)       { kv.key = key }
+func (kv *keyCapturingKV) AddFloat64(key string, val float64) { kv.key = key }
+func (kv *keyCapturingKV) AddInt(key string, val int)         { kv.key = key }
+func (kv *keyCapturingKV) AddInt64(key string, val int64)     { kv.key = key }
+func (kv *keyCapturingKV) AddUint(key string, val uint)       { kv.key = key }
+func (kv *keyCapturingKV) AddUint64(key string, val uint64)   { kv.key = key }
+func (kv *keyCapturingKV) AddUintptr(key string, val uintptr) { kv.key = key }
+func (kv *keyCapturingKV) AddString(key, val string)          { kv.key = key }
+
+func (kv *keyCapturingKV) AddObject(key string, val interface{}) error {
+	kv.key = key
+	return nil
+}
+
+func (kv *keyCapturingKV) AddMarshaler(key string, m zap.LogMarshaler) error {
+	kv.key = key
+	return nil
+}
+
+func fieldKey(f zap.Field) string {
+	var kv keyCapturingKV
+	f.AddTo(&kv)
+	return kv.key
+}
+
+func resetExtractors() {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors = map[string]Extractor{}
+	extractorOrder = nil
+}
+
+func TestRegisterContextExtractorStableOrder(t *testing.T) {
+	resetExtractors()
+	defer resetExtractors()
+
+	for _, key := range []string{"c", "a", "b"} {
+		key := key
+		RegisterContextExtractor(key, func(ctx context.Context) (zap.Field, bool) {
+			return zap.String(key, key), true
+		})
+	}
+	// Re-registering an existing key must not move it in the order.
+	RegisterContextExtractor("a", func(ctx context.Context) (zap.Field, bool) {
+		return zap.String("a", "a2"), true
+	})
+
+	want := []string{"c", "a", "b"}
+	for i := 0; i < 20; i++ {
+		fields := extractFields(context.Background())
+		if len(fields) != len(want) {
+			t.Fatalf("extractFields returned %d fields, want %d", len(fields), len(want))
+		}
+		for j, f := range fields {
+			if key := fieldKey(f); key != want[j] {
+				t.Fatalf("run %d: field %d key = %q, want %q (order must be stable across calls)", i, j, key, want[j])
+			}
+		}
+	}
+}
+
+func TestInfoCtxAndWarnCtxRecordSpanEventAtThreshold(t *testing.T) {
+	base := &fakeLogger{}
+	l := New(base)
+	l.SetSpanEventLevel(zap.WarnLevel)
+	span := &fakeSpan{}
+	ctx := WithSpanContext(context.Background(), span)
+
+	l.InfoCtx(ctx, "below threshold")
+	if got := span.snapshot(); len(got) != 0 {
+		t.Fatalf("InfoCtx below spanEventLevel recorded %v, want no span events", got)
+	}
+
+	l.WarnCtx(ctx, "at threshold")
+	if got := span.snapshot(); len(got) != 1 || got[0] != "at threshold" {
+		t.Fatalf("WarnCtx at spanEventLevel recorded %v, want [\"at threshold\"]", got)
+	}
+}
+
+func TestPanicCtxAndFatalCtxRecordSpanEvents(t *testing.T) {
+	base := &fakeLogger{}
+	l := New(base)
+	span := &fakeSpan{}
+	ctx := WithSpanContext(context.Background(), span)
+
+	func() {
+		defer func() { recover() }()
+		l.PanicCtx(ctx, "panic msg")
+	}()
+	l.FatalCtx(ctx, "fatal msg")
+
+	got := span.snapshot()
+	if len(got) != 2 || got[0] != "panic msg" || got[1] != "fatal msg" {
+		t.Fatalf("span events = %v, want [\"panic msg\" \"fatal msg\"]", got)
+	}
+}
+
+func TestCheckCtxWriteRecordsSpanEventAndCallsBase(t *testing.T) {
+	base := &fakeLogger{}
+	l := New(base)
+	l.SetSpanEventLevel(zap.InfoLevel)
+	span := &fakeSpan{}
+	ctx := WithSpanContext(context.Background(), span)
+
+	cm := l.CheckCtx(ctx, zap.InfoLevel, "checked")
+	if cm == nil {
+		t.Fatal("CheckCtx returned nil for an enabled level")
+	}
+	cm.Write()
+
+	if got := span.snapshot(); len(got) != 1 || got[0] != "checked" {
+		t.Fatalf("Check(...).Write(...) span events = %v, want [\"checked\"]", got)
+	}
+	calls := base.snapshot()
+	if len(calls) != 1 || calls[0].msg != "checked" {
+		t.Fatalf("base received calls %+v, want one call for \"checked\"", calls)
+	}
+}
+
+func TestCheckCtxWritePrefersCtxLogger(t *testing.T) {
+	base := &ctxLoggerFake{}
+	l := New(base)
+	ctx := context.Background()
+
+	cm := l.CheckCtx(ctx, zap.InfoLevel, "via ctxlogger")
+	cm.Write()
+
+	if len(base.ctxCalls) != 1 {
+		t.Fatalf("LogCtx called %d times, want 1 (Log should not be used when CtxLogger is available)", len(base.ctxCalls))
+	}
+}
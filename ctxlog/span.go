@@ -0,0 +1,67 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ctxlog
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// SpanContext is the minimal subset of an OpenTelemetry (or similarly
+// shaped) span context that ctxlog needs in order to tag log records with
+// trace_id/span_id. ctxlog never imports otel itself: application code
+// wires its tracer's span into a context via WithSpanContext.
+type SpanContext interface {
+	TraceID() string
+	SpanID() string
+}
+
+// SpanRecorder is implemented by a SpanContext that can also record a log
+// record as a span event, the way OpenTelemetry spans support AddEvent.
+// Loggers only call AddEvent for records at or above the configured
+// SetSpanEventLevel; converting fields to whatever attribute type the
+// underlying tracer wants is the adapter's job, not ctxlog's.
+type SpanRecorder interface {
+	SpanContext
+	AddEvent(name string, fields []zap.Field)
+}
+
+type spanContextKeyType struct{}
+
+var spanContextKey spanContextKeyType
+
+// WithSpanContext returns a copy of ctx carrying sc, so that subsequent
+// Ctx-suffixed calls inject trace_id/span_id fields -- and, if sc also
+// implements SpanRecorder, record the log as a span event.
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey, sc)
+}
+
+func spanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey).(SpanContext)
+	return sc, ok
+}
+
+func spanRecorderFromContext(ctx context.Context) (SpanRecorder, bool) {
+	rec, ok := ctx.Value(spanContextKey).(SpanRecorder)
+	return rec, ok
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+// LevelFilter wraps inner so that only records within [min, max] reach it.
+// PanicLevel and FatalLevel always pass through, matching the exception
+// multiLogger.Check already makes for them, since those levels must always
+// terminate the process regardless of any sink's configured window.
+//
+// This is meant to slot into a TeeLogger so each sub-logger can have its
+// own severity window, e.g. debug and up to a file, warn and up to stderr,
+// error and up to syslog.
+func LevelFilter(min, max Level, inner Logger) Logger {
+	return &levelFilter{min: min, max: max, inner: inner}
+}
+
+type levelFilter struct {
+	min, max Level
+	inner    Logger
+}
+
+func (f *levelFilter) inWindow(lvl Level) bool {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		return true
+	default:
+		return lvl >= f.min && lvl <= f.max
+	}
+}
+
+func (f *levelFilter) Log(lvl Level, msg string, fields ...Field) {
+	if f.inWindow(lvl) {
+		f.inner.Log(lvl, msg, fields...)
+	}
+}
+
+func (f *levelFilter) Debug(msg string, fields ...Field) {
+	if f.inWindow(DebugLevel) {
+		f.inner.Debug(msg, fields...)
+	}
+}
+
+func (f *levelFilter) Info(msg string, fields ...Field) {
+	if f.inWindow(InfoLevel) {
+		f.inner.Info(msg, fields...)
+	}
+}
+
+func (f *levelFilter) Warn(msg string, fields ...Field) {
+	if f.inWindow(WarnLevel) {
+		f.inner.Warn(msg, fields...)
+	}
+}
+
+func (f *levelFilter) Error(msg string, fields ...Field) {
+	if f.inWindow(ErrorLevel) {
+		f.inner.Error(msg, fields...)
+	}
+}
+
+func (f *levelFilter) DFatal(msg string, fields ...Field) {
+	if f.inWindow(ErrorLevel) {
+		f.inner.DFatal(msg, fields...)
+	}
+}
+
+// Panic always runs: PanicLevel is never filtered out.
+func (f *levelFilter) Panic(msg string, fields ...Field) {
+	f.inner.Panic(msg, fields...)
+}
+
+// Fatal always runs: FatalLevel is never filtered out.
+func (f *levelFilter) Fatal(msg string, fields ...Field) {
+	f.inner.Fatal(msg, fields...)
+}
+
+func (f *levelFilter) With(fields ...Field) Logger {
+	return &levelFilter{min: f.min, max: f.max, inner: f.inner.With(fields...)}
+}
+
+// Check returns nil outside [min, max], except for PanicLevel and
+// FatalLevel, mirroring multiLogger.Check's exception for those levels.
+func (f *levelFilter) Check(lvl Level, msg string) *CheckedMessage {
+	if !f.inWindow(lvl) {
+		return nil
+	}
+	return f.inner.Check(lvl, msg)
+}
+
+// FieldMapper wraps inner so that fn runs over every Field before it
+// reaches inner, including the Fields accumulated by With. This is meant
+// for cross-cutting concerns like PII redaction, key renaming, or tenant
+// tagging applied uniformly regardless of which Logger is underneath.
+func FieldMapper(fn func([]Field) []Field, inner Logger) Logger {
+	return &fieldMapper{fn: fn, inner: inner}
+}
+
+type fieldMapper struct {
+	fn    func([]Field) []Field
+	inner Logger
+}
+
+func (m *fieldMapper) Log(lvl Level, msg string, fields ...Field) {
+	m.inner.Log(lvl, msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Debug(msg string, fields ...Field) {
+	m.inner.Debug(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Info(msg string, fields ...Field) {
+	m.inner.Info(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Warn(msg string, fields ...Field) {
+	m.inner.Warn(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Error(msg string, fields ...Field) {
+	m.inner.Error(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) DFatal(msg string, fields ...Field) {
+	m.inner.DFatal(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Panic(msg string, fields ...Field) {
+	m.inner.Panic(msg, m.fn(fields)...)
+}
+
+func (m *fieldMapper) Fatal(msg string, fields ...Field) {
+	m.inner.Fatal(msg, m.fn(fields)...)
+}
+
+// With runs fn over fields too, so the accumulated context carried by the
+// returned Logger is transformed the same way as everything logged through
+// it afterwards.
+func (m *fieldMapper) With(fields ...Field) Logger {
+	return &fieldMapper{fn: m.fn, inner: m.inner.With(m.fn(fields)...)}
+}
+
+func (m *fieldMapper) Check(lvl Level, msg string) *CheckedMessage {
+	if cm := m.inner.Check(lvl, msg); cm.OK() {
+		return NewCheckedMessage(m, lvl, msg)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+	"testing"
+)
+
+// fieldAccumulatingLogger is a Logger test double that records the fields
+// bound by With alongside each call's own fields, so tests can tell whether
+// a decorator's With actually transforms what was accumulated rather than
+// only what's passed to a later Log call.
+type fieldAccumulatingLogger struct {
+	mu    sync.Mutex
+	bound []Field
+	calls []call
+}
+
+func (a *fieldAccumulatingLogger) record(lvl Level, fields []Field) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	all := append(append([]Field(nil), a.bound...), fields...)
+	a.calls = append(a.calls, call{lvl, "", all})
+}
+
+func (a *fieldAccumulatingLogger) Log(lvl Level, msg string, fields ...Field) { a.record(lvl, fields) }
+func (a *fieldAccumulatingLogger) Debug(msg string, fields ...Field)          { a.record(DebugLevel, fields) }
+func (a *fieldAccumulatingLogger) Info(msg string, fields ...Field)           { a.record(InfoLevel, fields) }
+func (a *fieldAccumulatingLogger) Warn(msg string, fields ...Field)           { a.record(WarnLevel, fields) }
+func (a *fieldAccumulatingLogger) Error(msg string, fields ...Field)          { a.record(ErrorLevel, fields) }
+func (a *fieldAccumulatingLogger) DFatal(msg string, fields ...Field)         { a.record(ErrorLevel, fields) }
+func (a *fieldAccumulatingLogger) Panic(msg string, fields ...Field)          { a.record(PanicLevel, fields) }
+func (a *fieldAccumulatingLogger) Fatal(msg string, fields ...Field)          { a.record(FatalLevel, fields) }
+
+func (a *fieldAccumulatingLogger) With(fields ...Field) Logger {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &fieldAccumulatingLogger{bound: append(append([]Field(nil), a.bound...), fields...)}
+}
+
+func (a *fieldAccumulatingLogger) Check(lvl Level, msg string) *CheckedMessage {
+	return NewCheckedMessage(a, lvl, msg)
+}
+
+func (a *fieldAccumulatingLogger) snapshot() []call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]call(nil), a.calls...)
+}
+
+func TestLevelFilterDropsOutsideWindowButPanicAndFatalAlwaysPass(t *testing.T) {
+	inner := &capturingLogger{}
+	f := LevelFilter(WarnLevel, ErrorLevel, inner)
+
+	f.Debug("below window")
+	f.Info("below window")
+	f.Warn("in window")
+	f.Error("in window")
+
+	inner.mu.Lock()
+	got := len(inner.logs)
+	inner.mu.Unlock()
+	if got != 2 {
+		t.Fatalf("got %d Log calls for Debug/Info/Warn/Error, want 2 (only Warn and Error are in [Warn, Error])", got)
+	}
+
+	f.Panic("panics outside window")
+	f.Fatal("fatals outside window")
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if len(inner.logs) != 3 || inner.logs[2].lvl != PanicLevel {
+		t.Fatalf("Panic did not reach inner despite being outside [Warn, Error]: logs = %+v", inner.logs)
+	}
+	if len(inner.fatals) != 1 {
+		t.Fatalf("Fatal did not reach inner despite being outside [Warn, Error]: fatals = %+v", inner.fatals)
+	}
+}
+
+func TestLevelFilterCheckHonorsWindowExceptPanicAndFatal(t *testing.T) {
+	inner := &capturingLogger{}
+	f := LevelFilter(WarnLevel, ErrorLevel, inner)
+
+	if cm := f.Check(InfoLevel, "below window"); cm != nil {
+		t.Fatal("Check(InfoLevel) = non-nil, want nil outside [Warn, Error]")
+	}
+	if cm := f.Check(WarnLevel, "in window"); cm == nil {
+		t.Fatal("Check(WarnLevel) = nil, want non-nil inside [Warn, Error]")
+	}
+	if cm := f.Check(PanicLevel, "panic always checks"); cm == nil {
+		t.Fatal("Check(PanicLevel) = nil, want non-nil regardless of the configured window")
+	}
+	if cm := f.Check(FatalLevel, "fatal always checks"); cm == nil {
+		t.Fatal("Check(FatalLevel) = nil, want non-nil regardless of the configured window")
+	}
+}
+
+func redactFields(fields []Field) []Field {
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		out[i] = String(fieldKey(f), "REDACTED")
+	}
+	return out
+}
+
+func TestFieldMapperTransformsFieldsBeforeInner(t *testing.T) {
+	inner := &capturingLogger{}
+	m := FieldMapper(redactFields, inner)
+
+	m.Info("secret", String("password", "hunter2"))
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if len(inner.logs) != 1 {
+		t.Fatalf("got %d Log calls, want 1", len(inner.logs))
+	}
+	if got, ok := fieldString(inner.logs[0].fields, "password"); !ok || got != "REDACTED" {
+		t.Fatalf("password field = %q, want it redacted before reaching inner", got)
+	}
+}
+
+func TestFieldMapperWithTransformsAccumulatedFields(t *testing.T) {
+	inner := &fieldAccumulatingLogger{}
+	m := FieldMapper(redactFields, inner).With(String("password", "hunter2"))
+
+	scoped, ok := m.(*fieldMapper)
+	if !ok {
+		t.Fatalf("With returned %T, want *fieldMapper", m)
+	}
+	boundInner, ok := scoped.inner.(*fieldAccumulatingLogger)
+	if !ok {
+		t.Fatalf("fieldMapper.inner = %T, want *fieldAccumulatingLogger", scoped.inner)
+	}
+
+	m.Info("secret")
+
+	calls := boundInner.snapshot()
+	if len(calls) != 1 {
+		t.Fatalf("got %d Log calls, want 1", len(calls))
+	}
+	if got, ok := fieldString(calls[0].fields, "password"); !ok || got != "REDACTED" {
+		t.Fatalf("password field accumulated via With = %q, want it redacted before reaching inner", got)
+	}
+}
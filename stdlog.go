@@ -0,0 +1,129 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// stdLogWriter adapts a Logger to io.Writer so it can back a *log.Logger,
+// recovering the real call site (past both the stdlib log package's own
+// frames and this shim) via runtime.Caller rather than trusting whatever
+// prefix/date/file log already stamped onto the line.
+type stdLogWriter struct {
+	log Logger
+	lvl Level
+}
+
+// NewStdLogAt returns a *log.Logger that writes through l at lvl. Every
+// record gets a source="stdlib" field and, when available, a caller field
+// pointing at the real call site rather than this shim.
+//
+// Unlike RedirectStdLog, the *log.Logger returned here is a dedicated
+// instance: if lvl is FatalLevel, every call made through it -- whether
+// Print, Fatal, or Panic -- is treated as fatal and goes through l.Fatal, so
+// it correctly gets TeeLogger's ordered fan-out-then-exit semantics. Use
+// NewStdLogAt(l, FatalLevel) for call sites that specifically want
+// Logger.Fatal behavior; reserve it for code paths that actually call
+// .Fatal/.Fatalln on the returned logger.
+func NewStdLogAt(l Logger, lvl Level) *log.Logger {
+	return log.New(&stdLogWriter{log: l, lvl: lvl}, "", 0)
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	fields := []Field{String("source", "stdlib")}
+	if file, line, ok := stdLogCaller(); ok {
+		fields = append(fields, String("caller", callerString(file, line)))
+	}
+
+	if w.lvl == FatalLevel {
+		// Every write through this instance is treated as fatal, so Logger's
+		// own Fatal semantics (TeeLogger's fan-out-then-exit included) apply
+		// before we terminate, rather than calling _exit ourselves. This is
+		// only correct when w.lvl was set to FatalLevel for this purpose, as
+		// NewStdLogAt's doc comment describes; see RedirectStdLog's doc
+		// comment for why the stdlib's global log.Fatal can't be handled
+		// this way in general.
+		w.log.Fatal(msg, fields...)
+		return len(p), nil
+	}
+	w.log.Log(w.lvl, msg, fields...)
+	return len(p), nil
+}
+
+// stdLogCaller walks past the stdlib log package's own frames and this
+// file's frame to find the first caller outside of both.
+func stdLogCaller() (file string, line int, ok bool) {
+	for skip := 2; skip < 10; skip++ {
+		_, f, l, found := runtime.Caller(skip)
+		if !found {
+			return "", 0, false
+		}
+		if strings.HasSuffix(f, "/log/log.go") || strings.HasSuffix(f, "stdlog.go") {
+			continue
+		}
+		return f, l, true
+	}
+	return "", 0, false
+}
+
+func callerString(file string, line int) string {
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		file = file[i+1:]
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+// RedirectStdLog routes the standard library's log package -- log.Print,
+// log.Fatal, and any third-party code that logs through it -- to l at lvl.
+// It returns a restore func that puts the stdlib logger's previous output
+// and flags back the way it found them.
+//
+// Every record written through the global logger while redirected is
+// logged at the single, static lvl passed here, whether it came from
+// log.Print or log.Fatal: an io.Writer has no way to tell which stdlib
+// function produced a given Write call. That means log.Fatal/log.Panic
+// calls made by third-party code during the redirect are logged at lvl,
+// not FatalLevel, and then terminate the process via the stdlib's own
+// os.Exit/panic immediately afterward -- outside of Logger.Fatal/Panic and
+// TeeLogger's ordered fan-out-then-exit, which can't be interposed on at
+// that point. If a code path specifically needs Logger.Fatal semantics for
+// its own fatal calls, give it its own logger via
+// NewStdLogAt(l, FatalLevel) instead of relying on the global redirect.
+func RedirectStdLog(l Logger, lvl Level) func() {
+	flags := log.Flags()
+	prefix := log.Prefix()
+	writer := log.Writer()
+
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(&stdLogWriter{log: l, lvl: lvl})
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(writer)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// capturingLogger is a Logger test double that records the level each call
+// arrived at, distinguishing Log from Fatal -- the distinction stdLogWriter
+// is responsible for getting right.
+type capturingLogger struct {
+	mu     sync.Mutex
+	logs   []call
+	fatals []call
+}
+
+type call struct {
+	lvl    Level
+	msg    string
+	fields []Field
+}
+
+func (c *capturingLogger) Log(lvl Level, msg string, fields ...Field) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logs = append(c.logs, call{lvl, msg, fields})
+}
+
+func (c *capturingLogger) Debug(msg string, fields ...Field) { c.Log(DebugLevel, msg, fields...) }
+func (c *capturingLogger) Info(msg string, fields ...Field)  { c.Log(InfoLevel, msg, fields...) }
+func (c *capturingLogger) Warn(msg string, fields ...Field)  { c.Log(WarnLevel, msg, fields...) }
+func (c *capturingLogger) Error(msg string, fields ...Field) { c.Log(ErrorLevel, msg, fields...) }
+func (c *capturingLogger) DFatal(msg string, fields ...Field) {
+	c.Log(ErrorLevel, msg, fields...)
+}
+
+func (c *capturingLogger) Panic(msg string, fields ...Field) { c.Log(PanicLevel, msg, fields...) }
+
+func (c *capturingLogger) Fatal(msg string, fields ...Field) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fatals = append(c.fatals, call{FatalLevel, msg, fields})
+}
+
+func (c *capturingLogger) With(fields ...Field) Logger { return c }
+func (c *capturingLogger) Check(lvl Level, msg string) *CheckedMessage {
+	return NewCheckedMessage(c, lvl, msg)
+}
+
+// recordingKV is a minimal KeyValue test double. Field has no exported
+// accessors, so tests recover a field's key (and string value, if any) by
+// replaying it through AddTo.
+type recordingKV struct {
+	key      string
+	strVal   string
+	isString bool
+}
+
+func (kv *recordingKV) AddBool(key string, val bool)       { kv.key = key }
+func (kv *recordingKV) AddFloat64(key string, val float64) { kv.key = key }
+func (kv *recordingKV) AddInt(key string, val int)         { kv.key = key }
+func (kv *recordingKV) AddInt64(key string, val int64)     { kv.key = key }
+func (kv *recordingKV) AddUint(key string, val uint)       { kv.key = key }
+func (kv *recordingKV) AddUint64(key string, val uint64)   { kv.key = key }
+func (kv *recordingKV) AddUintptr(key string, val uintptr) { kv.key = key }
+
+func (kv *recordingKV) AddString(key, val string) {
+	kv.key, kv.strVal, kv.isString = key, val, true
+}
+
+func (kv *recordingKV) AddObject(key string, val interface{}) error {
+	kv.key = key
+	return nil
+}
+
+func (kv *recordingKV) AddMarshaler(key string, m LogMarshaler) error {
+	kv.key = key
+	return nil
+}
+
+func fieldKey(f Field) string {
+	var kv recordingKV
+	f.AddTo(&kv)
+	return kv.key
+}
+
+func fieldString(fields []Field, key string) (string, bool) {
+	for _, f := range fields {
+		var kv recordingKV
+		f.AddTo(&kv)
+		if kv.key == key && kv.isString {
+			return kv.strVal, true
+		}
+	}
+	return "", false
+}
+
+func TestNewStdLogAtRoutesToLog(t *testing.T) {
+	c := &capturingLogger{}
+	std := NewStdLogAt(c, InfoLevel)
+	std.Print("hello from stdlib")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.logs) != 1 || len(c.fatals) != 0 {
+		t.Fatalf("got %d Log calls and %d Fatal calls, want 1 and 0", len(c.logs), len(c.fatals))
+	}
+	if c.logs[0].lvl != InfoLevel || c.logs[0].msg != "hello from stdlib" {
+		t.Fatalf("recorded call = %+v, want {InfoLevel, \"hello from stdlib\"}", c.logs[0])
+	}
+	if src, ok := fieldString(c.logs[0].fields, "source"); !ok || src != "stdlib" {
+		t.Fatalf("fields = %v, want a source=\"stdlib\" field", c.logs[0].fields)
+	}
+	if caller, ok := fieldString(c.logs[0].fields, "caller"); !ok || !strings.Contains(caller, "stdlog_test.go") {
+		t.Fatalf("caller field = %q, want it to name this test file", caller)
+	}
+}
+
+func TestNewStdLogAtFatalLevelRoutesEveryCallToFatal(t *testing.T) {
+	c := &capturingLogger{}
+	std := NewStdLogAt(c, FatalLevel)
+	// log.Logger.Print and log.Logger.Fatal both funnel through the same
+	// Write call on our writer; NewStdLogAt(c, FatalLevel)'s contract is
+	// that every write through this dedicated instance is fatal.
+	std.Print("should still be treated as fatal")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.fatals) != 1 || len(c.logs) != 0 {
+		t.Fatalf("got %d Fatal calls and %d Log calls, want 1 and 0", len(c.fatals), len(c.logs))
+	}
+	if c.fatals[0].msg != "should still be treated as fatal" {
+		t.Fatalf("fatal call = %+v, want msg %q", c.fatals[0], "should still be treated as fatal")
+	}
+}
+
+func TestRedirectStdLogRestoresPreviousState(t *testing.T) {
+	origFlags := log.Flags()
+	origPrefix := log.Prefix()
+	origWriter := log.Writer()
+
+	c := &capturingLogger{}
+	restore := RedirectStdLog(c, WarnLevel)
+	log.Print("through the redirect")
+	restore()
+
+	if log.Flags() != origFlags || log.Prefix() != origPrefix || log.Writer() != origWriter {
+		t.Fatal("restore() did not put back the stdlib logger's original flags/prefix/output")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.logs) != 1 || c.logs[0].lvl != WarnLevel || c.logs[0].msg != "through the redirect" {
+		t.Fatalf("got calls %+v, want one WarnLevel call for \"through the redirect\"", c.logs)
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy controls what an async sink does when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the record that just failed to enqueue, keeping
+	// whatever was already buffered.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest buffered record to make room for the
+	// new one.
+	DropOldest
+	// Block waits, with no timeout, until there's room in the buffer.
+	Block
+	// BlockWithTimeout waits up to AsyncOptions.FlushTimeout for room in the
+	// buffer before falling back to DropNewest behavior.
+	BlockWithTimeout
+)
+
+// AsyncOptions configures TeeLoggerAsync.
+type AsyncOptions struct {
+	// BufferSize is the number of records each sink's ring buffer can hold
+	// before OverflowPolicy kicks in. Defaults to 1024 if zero.
+	BufferSize int
+	// OverflowPolicy determines what happens when a sink falls behind.
+	OverflowPolicy OverflowPolicy
+	// OnDrop, if non-nil, is called synchronously on the producer goroutine
+	// whenever a record is dropped for sinkIndex, the position of the
+	// sub-logger in the logs passed to TeeLoggerAsync.
+	OnDrop func(sinkIndex int, lvl Level, msg string, fields []Field)
+	// FlushTimeout bounds how long Flush will wait for a sink to catch up,
+	// and how long BlockWithTimeout waits for room in the buffer. Defaults
+	// to 5 seconds if zero.
+	FlushTimeout time.Duration
+}
+
+func (o AsyncOptions) bufferSize() int {
+	if o.BufferSize > 0 {
+		return o.BufferSize
+	}
+	return 1024
+}
+
+func (o AsyncOptions) flushTimeout() time.Duration {
+	if o.FlushTimeout > 0 {
+		return o.FlushTimeout
+	}
+	return 5 * time.Second
+}
+
+// record is a single enqueued log call. fields is always a fresh copy, so
+// it's safe to retain across the boundary to the worker goroutine even if
+// the caller reuses its own field slice.
+//
+// A record with a non-nil done channel asks the worker to signal once it's
+// been handled, having already processed everything enqueued ahead of it
+// in the same buffer. barrier records (used by Flush) carry done but skip
+// the actual Log call; Fatal/Panic records carry both a real log call and
+// done, so the caller can block until delivery is certain. dfatal records
+// ask the worker to call the sub-logger's own DFatal rather than Log, so a
+// sub-logger that escalates DFatal to Fatal in dev mode still does.
+type record struct {
+	lvl     Level
+	msg     string
+	fields  []Field
+	barrier bool
+	dfatal  bool
+	done    chan struct{}
+}
+
+// Flusher is implemented by Loggers that buffer records asynchronously.
+// Flush blocks until every sink has drained its buffer, or until
+// AsyncOptions.FlushTimeout elapses, whichever comes first.
+type Flusher interface {
+	Flush() error
+}
+
+// ErrFlushTimeout is returned by Flush when one or more sinks didn't drain
+// within AsyncOptions.FlushTimeout.
+var ErrFlushTimeout = errors.New("zap: flush timed out waiting for async sink")
+
+// TeeLoggerAsync is TeeLogger's non-blocking sibling: records are fanned out
+// to each sub-logger through a bounded per-sink buffer served by a
+// dedicated goroutine, so a slow sink can't stall the hot path for the
+// others. Fatal and Panic are the exception -- they synchronously drain
+// every buffer before terminating, so no record is lost on the way out.
+//
+// The goroutine and buffer backing each sub-logger are created once, here,
+// and shared by every Logger that With returns from this tee: With adds to
+// an accumulated field list rather than spinning up new sinks, so scoping a
+// logger per request doesn't leak a goroutine per call.
+func TeeLoggerAsync(opts AsyncOptions, logs ...Logger) Logger {
+	switch len(logs) {
+	case 0:
+		return nil
+	case 1:
+		return logs[0]
+	}
+
+	sinks := make([]*asyncSink, len(logs))
+	for i, log := range logs {
+		sinks[i] = newAsyncSink(log, opts)
+	}
+	return &asyncMultiLogger{opts: opts, sinks: sinks}
+}
+
+type asyncMultiLogger struct {
+	opts  AsyncOptions
+	sinks []*asyncSink
+	// extra holds the Fields accumulated by With. It's never mutated in
+	// place; With always produces a fresh slice.
+	extra []Field
+}
+
+// withExtra copies fields into a fresh slice, prefixed by any Fields
+// accumulated via With. The copy is what makes it safe to retain fields
+// across the boundary to a sink's worker goroutine even if the caller
+// reuses its own slice.
+func (al *asyncMultiLogger) withExtra(fields []Field) []Field {
+	cp := make([]Field, 0, len(al.extra)+len(fields))
+	cp = append(cp, al.extra...)
+	cp = append(cp, fields...)
+	return cp
+}
+
+func (al *asyncMultiLogger) Log(lvl Level, msg string, fields ...Field) {
+	al.enqueue(lvl, msg, fields)
+}
+
+func (al *asyncMultiLogger) Debug(msg string, fields ...Field) { al.enqueue(DebugLevel, msg, fields) }
+func (al *asyncMultiLogger) Info(msg string, fields ...Field)  { al.enqueue(InfoLevel, msg, fields) }
+func (al *asyncMultiLogger) Warn(msg string, fields ...Field)  { al.enqueue(WarnLevel, msg, fields) }
+func (al *asyncMultiLogger) Error(msg string, fields ...Field) { al.enqueue(ErrorLevel, msg, fields) }
+
+func (al *asyncMultiLogger) DFatal(msg string, fields ...Field) {
+	all := al.withExtra(fields)
+	for i, sink := range al.sinks {
+		sink.enqueue(i, record{lvl: ErrorLevel, msg: msg, fields: all, dfatal: true})
+	}
+}
+
+func (al *asyncMultiLogger) Panic(msg string, fields ...Field) {
+	al.drainSync(PanicLevel, msg, fields)
+	panic(msg)
+}
+
+func (al *asyncMultiLogger) Fatal(msg string, fields ...Field) {
+	al.drainSync(FatalLevel, msg, fields)
+	_exit(1)
+}
+
+func (al *asyncMultiLogger) enqueue(lvl Level, msg string, fields []Field) {
+	all := al.withExtra(fields)
+	for i, sink := range al.sinks {
+		sink.enqueue(i, record{lvl: lvl, msg: msg, fields: all})
+	}
+}
+
+// drainSync pushes the record through each sink's own buffer -- exactly
+// like a normal log call, never bypassing it -- and waits for every sink's
+// worker to actually reach and process it. Because the buffer is FIFO,
+// reaching this record means every record enqueued ahead of it on that sink
+// has already been delivered, so no buffered record is lost when Fatal or
+// Panic terminates the process right after this returns.
+func (al *asyncMultiLogger) drainSync(lvl Level, msg string, fields []Field) {
+	all := al.withExtra(fields)
+	var wg sync.WaitGroup
+	wg.Add(len(al.sinks))
+	for _, sink := range al.sinks {
+		sink := sink
+		go func() {
+			defer wg.Done()
+			sink.deliverAndWait(record{lvl: lvl, msg: msg, fields: all})
+		}()
+	}
+	wg.Wait()
+}
+
+// With accumulates fields rather than creating new sinks: the returned
+// Logger shares this one's buffers and worker goroutines, so scoping a
+// logger (e.g. per request) doesn't leak resources.
+func (al *asyncMultiLogger) With(fields ...Field) Logger {
+	return &asyncMultiLogger{opts: al.opts, sinks: al.sinks, extra: al.withExtra(fields)}
+}
+
+func (al *asyncMultiLogger) Check(lvl Level, msg string) *CheckedMessage {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		return NewCheckedMessage(al, lvl, msg)
+	}
+	for _, sink := range al.sinks {
+		if cm := sink.log.Check(lvl, msg); cm.OK() {
+			return NewCheckedMessage(al, lvl, msg)
+		}
+	}
+	return nil
+}
+
+// Flush blocks until every sink has processed everything enqueued before
+// this call, or until AsyncOptions.FlushTimeout elapses. Both enqueueing
+// the flush barrier and waiting for it to be reached are bounded by the
+// same deadline, so a sink whose buffer is already full -- the scenario
+// this whole feature exists to survive -- can't make Flush hang forever.
+func (al *asyncMultiLogger) Flush() error {
+	deadline := time.After(al.opts.flushTimeout())
+	for _, sink := range al.sinks {
+		done := make(chan struct{})
+		select {
+		case sink.buf <- record{barrier: true, done: done}:
+		case <-deadline:
+			return ErrFlushTimeout
+		}
+		select {
+		case <-done:
+		case <-deadline:
+			return ErrFlushTimeout
+		}
+	}
+	return nil
+}
+
+// asyncSink owns one sub-logger's buffer and the single goroutine that
+// serves it for the sink's whole lifetime; With on the owning
+// asyncMultiLogger never creates another one.
+type asyncSink struct {
+	log  Logger
+	opts AsyncOptions
+	buf  chan record
+}
+
+func newAsyncSink(log Logger, opts AsyncOptions) *asyncSink {
+	s := &asyncSink{
+		log:  log,
+		opts: opts,
+		buf:  make(chan record, opts.bufferSize()),
+	}
+	go s.run()
+	return s
+}
+
+func (s *asyncSink) run() {
+	for rec := range s.buf {
+		switch {
+		case rec.barrier:
+		case rec.dfatal:
+			s.log.DFatal(rec.msg, rec.fields...)
+		default:
+			s.log.Log(rec.lvl, rec.msg, rec.fields...)
+		}
+		if rec.done != nil {
+			close(rec.done)
+		}
+	}
+}
+
+func (s *asyncSink) enqueue(index int, rec record) {
+	switch s.opts.OverflowPolicy {
+	case Block:
+		s.buf <- rec
+		return
+	case BlockWithTimeout:
+		select {
+		case s.buf <- rec:
+			return
+		case <-time.After(s.opts.flushTimeout()):
+			s.drop(index, rec)
+			return
+		}
+	case DropOldest:
+		select {
+		case s.buf <- rec:
+			return
+		default:
+			select {
+			case old := <-s.buf:
+				s.drop(index, old)
+			default:
+			}
+			select {
+			case s.buf <- rec:
+			default:
+				s.drop(index, rec)
+			}
+			return
+		}
+	default: // DropNewest
+		select {
+		case s.buf <- rec:
+		default:
+			s.drop(index, rec)
+		}
+	}
+}
+
+func (s *asyncSink) drop(index int, rec record) {
+	if s.opts.OnDrop != nil {
+		s.opts.OnDrop(index, rec.lvl, rec.msg, rec.fields)
+	}
+}
+
+// deliverAndWait enqueues rec -- unconditionally, ignoring OverflowPolicy,
+// since Fatal/Panic records must never be dropped -- and blocks until the
+// worker has processed it and everything ahead of it.
+func (s *asyncSink) deliverAndWait(rec record) {
+	done := make(chan struct{})
+	rec.done = done
+	s.buf <- rec
+	<-done
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger is a Logger test double that appends every record it
+// receives to a slice, guarded by a mutex since TeeLoggerAsync delivers on
+// its own goroutine.
+type recordingLogger struct {
+	mu      sync.Mutex
+	logs    []string
+	dfatals []string
+}
+
+func (r *recordingLogger) Log(lvl Level, msg string, fields ...Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, msg)
+}
+
+func (r *recordingLogger) Debug(msg string, fields ...Field) { r.Log(DebugLevel, msg, fields...) }
+func (r *recordingLogger) Info(msg string, fields ...Field)  { r.Log(InfoLevel, msg, fields...) }
+func (r *recordingLogger) Warn(msg string, fields ...Field)  { r.Log(WarnLevel, msg, fields...) }
+func (r *recordingLogger) Error(msg string, fields ...Field) { r.Log(ErrorLevel, msg, fields...) }
+
+// DFatal is recorded separately from Log so tests can tell whether
+// TeeLoggerAsync actually called a sub-logger's own DFatal -- which may
+// escalate to Fatal in dev mode -- rather than downgrading it to a plain
+// Error log on the way through.
+func (r *recordingLogger) DFatal(msg string, fields ...Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dfatals = append(r.dfatals, msg)
+}
+func (r *recordingLogger) Panic(msg string, fields ...Field) { r.Log(PanicLevel, msg, fields...) }
+func (r *recordingLogger) Fatal(msg string, fields ...Field) { r.Log(FatalLevel, msg, fields...) }
+func (r *recordingLogger) With(fields ...Field) Logger       { return r }
+func (r *recordingLogger) Check(lvl Level, msg string) *CheckedMessage {
+	return NewCheckedMessage(r, lvl, msg)
+}
+
+func (r *recordingLogger) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.logs...)
+}
+
+func (r *recordingLogger) dfatalSnapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.dfatals...)
+}
+
+func TestAsyncMultiLoggerPanicDrainsBufferedRecords(t *testing.T) {
+	rec := &recordingLogger{}
+	log := TeeLoggerAsync(AsyncOptions{BufferSize: 4}, rec, &recordingLogger{})
+
+	for i := 0; i < 3; i++ {
+		log.Info("buffered")
+	}
+
+	func() {
+		defer func() { recover() }()
+		log.Panic("boom")
+	}()
+
+	got := rec.snapshot()
+	if len(got) != 4 {
+		t.Fatalf("got %d delivered records, want 4 (3 buffered + panic record): %v", len(got), got)
+	}
+	if got[3] != "boom" {
+		t.Fatalf("last delivered record = %q, want %q", got[3], "boom")
+	}
+}
+
+func TestAsyncMultiLoggerWithSharesSinks(t *testing.T) {
+	rec := &recordingLogger{}
+	log := TeeLoggerAsync(AsyncOptions{BufferSize: 4}, rec, &recordingLogger{})
+
+	base, ok := log.(*asyncMultiLogger)
+	if !ok {
+		t.Fatalf("TeeLoggerAsync returned %T, want *asyncMultiLogger", log)
+	}
+
+	scoped := log.With(String("request_id", "abc"))
+	for i := 0; i < 50; i++ {
+		scoped = scoped.With(Int("iteration", i))
+	}
+
+	got, ok := scoped.(*asyncMultiLogger)
+	if !ok {
+		t.Fatalf("With returned %T, want *asyncMultiLogger", scoped)
+	}
+	if len(got.sinks) != len(base.sinks) {
+		t.Fatalf("With changed the sink count: got %d, want %d", len(got.sinks), len(base.sinks))
+	}
+	for i := range got.sinks {
+		if got.sinks[i] != base.sinks[i] {
+			t.Fatalf("With created a new sink at index %d instead of sharing the original", i)
+		}
+	}
+}
+
+func TestAsyncMultiLoggerDFatalCallsSubLoggerDFatal(t *testing.T) {
+	rec := &recordingLogger{}
+	log := TeeLoggerAsync(AsyncOptions{BufferSize: 4}, rec, &recordingLogger{})
+
+	log.DFatal("escalate me")
+	if err := log.(Flusher).Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want nil", err)
+	}
+
+	if got := rec.dfatalSnapshot(); len(got) != 1 || got[0] != "escalate me" {
+		t.Fatalf("sub-logger DFatal calls = %v, want [\"escalate me\"]", got)
+	}
+	if got := rec.snapshot(); len(got) != 0 {
+		t.Fatalf("DFatal was delivered via Log instead of DFatal: %v", got)
+	}
+}
+
+// blockingLogger's Log method hangs until release is closed, simulating a
+// sink whose worker goroutine has fallen behind.
+type blockingLogger struct {
+	release chan struct{}
+}
+
+func (b *blockingLogger) Log(lvl Level, msg string, fields ...Field) { <-b.release }
+func (b *blockingLogger) Debug(msg string, fields ...Field)          { <-b.release }
+func (b *blockingLogger) Info(msg string, fields ...Field)           { <-b.release }
+func (b *blockingLogger) Warn(msg string, fields ...Field)           { <-b.release }
+func (b *blockingLogger) Error(msg string, fields ...Field)          { <-b.release }
+func (b *blockingLogger) DFatal(msg string, fields ...Field)         { <-b.release }
+func (b *blockingLogger) Panic(msg string, fields ...Field)          { <-b.release }
+func (b *blockingLogger) Fatal(msg string, fields ...Field)          { <-b.release }
+func (b *blockingLogger) With(fields ...Field) Logger                { return b }
+func (b *blockingLogger) Check(lvl Level, msg string) *CheckedMessage {
+	return NewCheckedMessage(b, lvl, msg)
+}
+
+func TestAsyncMultiLoggerFlushTimesOutOnFullBuffer(t *testing.T) {
+	slow := &blockingLogger{release: make(chan struct{})}
+	defer close(slow.release)
+
+	opts := AsyncOptions{BufferSize: 1, FlushTimeout: 20 * time.Millisecond}
+	log := TeeLoggerAsync(opts, slow, &recordingLogger{})
+
+	// The first record ties up slow's worker goroutine inside Log; the
+	// second fills its buffer, since BufferSize is 1. Both calls return
+	// immediately because enqueue never waits on the worker itself.
+	log.Info("first")
+	log.Info("second")
+
+	flusher, ok := log.(Flusher)
+	if !ok {
+		t.Fatalf("TeeLoggerAsync's result does not implement Flusher")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- flusher.Flush() }()
+
+	select {
+	case err := <-done:
+		if err != ErrFlushTimeout {
+			t.Fatalf("Flush() = %v, want ErrFlushTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Flush did not return within its own timeout window")
+	}
+}
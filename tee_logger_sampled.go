@@ -0,0 +1,274 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"sync"
+	"time"
+)
+
+// SamplingPolicy decides, for a given sub-logger of a SampledTeeLogger,
+// whether a particular record should be let through. Implementations must
+// be safe for concurrent use.
+type SamplingPolicy interface {
+	Sample(sinkIndex int, lvl Level, msg string, fields []Field) bool
+}
+
+// SamplingFunc adapts a plain function to a SamplingPolicy.
+type SamplingFunc func(lvl Level, msg string, fields []Field, sinkIndex int) bool
+
+// Sample implements SamplingPolicy.
+func (f SamplingFunc) Sample(sinkIndex int, lvl Level, msg string, fields []Field) bool {
+	return f(lvl, msg, fields, sinkIndex)
+}
+
+// RateLimited returns a SamplingPolicy that token-bucket rate-limits each
+// distinct (level, msg) key independently, allowing up to rate records per
+// tick with a burst of up to burst.
+func RateLimited(tick time.Duration, rate, burst int) SamplingPolicy {
+	return &rateLimitPolicy{tick: tick, rate: rate, burst: burst, buckets: map[string]*bucket{}}
+}
+
+type rateLimitPolicy struct {
+	tick  time.Duration
+	rate  int
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens   int
+	lastFill time.Time
+}
+
+func (p *rateLimitPolicy) Sample(_ int, lvl Level, msg string, _ []Field) bool {
+	key := lvl.String() + "|" + msg
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, ok := p.buckets[key]
+	now := time.Now()
+	if !ok {
+		b = &bucket{tokens: p.burst - 1, lastFill: now}
+		p.buckets[key] = b
+		return true
+	}
+
+	if elapsed := now.Sub(b.lastFill); elapsed >= p.tick {
+		ticks := int(elapsed / p.tick)
+		b.tokens += ticks * p.rate
+		if b.tokens > p.burst {
+			b.tokens = p.burst
+		}
+		b.lastFill = now
+	}
+
+	if b.tokens <= 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// BurstThenDecimate returns a SamplingPolicy matching zap's core sampler:
+// for each (level, msg) key, the first `first` records in every `tick`
+// window pass through unconditionally; after that, only 1 in `thereafter`
+// passes.
+func BurstThenDecimate(tick time.Duration, first, thereafter int) SamplingPolicy {
+	if thereafter < 1 {
+		thereafter = 1
+	}
+	return &burstPolicy{tick: tick, first: first, thereafter: thereafter, counters: map[string]*burstCounter{}}
+}
+
+type burstPolicy struct {
+	tick       time.Duration
+	first      int
+	thereafter int
+
+	mu       sync.Mutex
+	counters map[string]*burstCounter
+}
+
+type burstCounter struct {
+	windowStart time.Time
+	count       int64
+}
+
+func (p *burstPolicy) Sample(_ int, lvl Level, msg string, _ []Field) bool {
+	key := lvl.String() + "|" + msg
+
+	p.mu.Lock()
+	c, ok := p.counters[key]
+	now := time.Now()
+	if !ok || now.Sub(c.windowStart) >= p.tick {
+		c = &burstCounter{windowStart: now}
+		p.counters[key] = c
+	}
+	c.count++
+	n := c.count
+	p.mu.Unlock()
+
+	if n <= int64(p.first) {
+		return true
+	}
+	return (n-int64(p.first))%int64(p.thereafter) == 0
+}
+
+// SampledTeeLogger is TeeLogger's filtering sibling: each sub-logger
+// receives only the records policy lets through for it, rather than every
+// record passed to every sink. This lets a full-detail local file sink and
+// a sampled, expensive remote sink share the same call sites.
+//
+// Fatal and Panic always bypass policy and reach every sub-logger, exactly
+// as TeeLogger does.
+func SampledTeeLogger(policy SamplingPolicy, logs ...Logger) Logger {
+	if len(logs) == 0 {
+		return nil
+	}
+	return sampledMultiLogger{policy: policy, logs: logs}
+}
+
+type sampledMultiLogger struct {
+	policy SamplingPolicy
+	logs   []Logger
+}
+
+func (sl sampledMultiLogger) Log(lvl Level, msg string, fields ...Field) {
+	sl.log(lvl, msg, fields)
+}
+
+func (sl sampledMultiLogger) Debug(msg string, fields ...Field) { sl.log(DebugLevel, msg, fields) }
+func (sl sampledMultiLogger) Info(msg string, fields ...Field)  { sl.log(InfoLevel, msg, fields) }
+func (sl sampledMultiLogger) Warn(msg string, fields ...Field)  { sl.log(WarnLevel, msg, fields) }
+func (sl sampledMultiLogger) Error(msg string, fields ...Field) { sl.log(ErrorLevel, msg, fields) }
+
+// DFatal bypasses the sampling policy and reaches every sub-logger, like
+// Panic and Fatal: a sub-logger's DFatal may escalate to Fatal in dev mode,
+// and sampling it away would silently drop that escalation.
+func (sl sampledMultiLogger) DFatal(msg string, fields ...Field) {
+	for _, log := range sl.logs {
+		log.DFatal(msg, fields...)
+	}
+}
+
+func (sl sampledMultiLogger) Panic(msg string, fields ...Field) {
+	for _, log := range sl.logs {
+		log.Log(PanicLevel, msg, fields...)
+	}
+	panic(msg)
+}
+
+func (sl sampledMultiLogger) Fatal(msg string, fields ...Field) {
+	for _, log := range sl.logs {
+		log.Log(FatalLevel, msg, fields...)
+	}
+	_exit(1)
+}
+
+func (sl sampledMultiLogger) log(lvl Level, msg string, fields []Field) {
+	for i, log := range sl.logs {
+		if sl.policy.Sample(i, lvl, msg, fields) {
+			log.Log(lvl, msg, fields...)
+		}
+	}
+}
+
+func (sl sampledMultiLogger) With(fields ...Field) Logger {
+	logs := make([]Logger, len(sl.logs))
+	for i, log := range sl.logs {
+		logs[i] = log.With(fields...)
+	}
+	return sampledMultiLogger{policy: sl.policy, logs: logs}
+}
+
+// Check consults the sampling policy so that a call site using
+// Check(...).Write(...) skips field construction entirely for records that
+// every sink would drop anyway.
+//
+// The policy is consulted here, once per sink, and its verdicts are carried
+// into the CheckedMessage rather than re-consulted on Write: SamplingPolicy
+// implementations like RateLimited and BurstThenDecimate are stateful, so
+// calling Sample a second time for the same record -- once here, again in
+// sl.log -- would consume two tokens (or two decimation slots) for what the
+// caller sees as a single log call.
+func (sl sampledMultiLogger) Check(lvl Level, msg string) *CheckedMessage {
+	switch lvl {
+	case PanicLevel, FatalLevel:
+		return NewCheckedMessage(sl, lvl, msg)
+	}
+	allowed := make([]bool, len(sl.logs))
+	any := false
+	for i, log := range sl.logs {
+		if !sl.policy.Sample(i, lvl, msg, nil) {
+			continue
+		}
+		if cm := log.Check(lvl, msg); cm.OK() {
+			allowed[i] = true
+			any = true
+		}
+	}
+	if !any {
+		return nil
+	}
+	return NewCheckedMessage(sampledCheckedLogger{sl, allowed}, lvl, msg)
+}
+
+// sampledCheckedLogger backs the CheckedMessage returned by Check: it
+// delivers to exactly the sinks Check already sampled in, rather than
+// consulting the policy again on Write.
+type sampledCheckedLogger struct {
+	sl      sampledMultiLogger
+	allowed []bool
+}
+
+func (cl sampledCheckedLogger) log(lvl Level, msg string, fields []Field) {
+	for i, log := range cl.sl.logs {
+		if cl.allowed[i] {
+			log.Log(lvl, msg, fields...)
+		}
+	}
+}
+
+func (cl sampledCheckedLogger) Log(lvl Level, msg string, fields ...Field) {
+	cl.log(lvl, msg, fields)
+}
+
+func (cl sampledCheckedLogger) Debug(msg string, fields ...Field) { cl.log(DebugLevel, msg, fields) }
+func (cl sampledCheckedLogger) Info(msg string, fields ...Field)  { cl.log(InfoLevel, msg, fields) }
+func (cl sampledCheckedLogger) Warn(msg string, fields ...Field)  { cl.log(WarnLevel, msg, fields) }
+func (cl sampledCheckedLogger) Error(msg string, fields ...Field) { cl.log(ErrorLevel, msg, fields) }
+
+// DFatal delegates to sampledMultiLogger.DFatal, which bypasses the
+// sampling policy and reaches every sub-logger, like Panic and Fatal.
+func (cl sampledCheckedLogger) DFatal(msg string, fields ...Field) {
+	cl.sl.DFatal(msg, fields...)
+}
+
+func (cl sampledCheckedLogger) Panic(msg string, fields ...Field) { cl.sl.Panic(msg, fields...) }
+func (cl sampledCheckedLogger) Fatal(msg string, fields ...Field) { cl.sl.Fatal(msg, fields...) }
+func (cl sampledCheckedLogger) With(fields ...Field) Logger       { return cl.sl.With(fields...) }
+func (cl sampledCheckedLogger) Check(lvl Level, msg string) *CheckedMessage {
+	return cl.sl.Check(lvl, msg)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zap
+
+import (
+	"testing"
+	"time"
+)
+
+// countingPolicy records every Sample call it receives, so a test can
+// assert exactly how many times a single logical log call consulted the
+// policy.
+type countingPolicy struct {
+	calls int
+	allow bool
+}
+
+func (p *countingPolicy) Sample(sinkIndex int, lvl Level, msg string, fields []Field) bool {
+	p.calls++
+	return p.allow
+}
+
+func TestSampledTeeLoggerCheckWriteSamplesOnce(t *testing.T) {
+	policy := &countingPolicy{allow: true}
+	rec := &recordingLogger{}
+	log := SampledTeeLogger(policy, rec)
+
+	cm := log.Check(InfoLevel, "hello")
+	if !cm.OK() {
+		t.Fatalf("Check(InfoLevel, ...).OK() = false, want true")
+	}
+	cm.Write()
+
+	if policy.calls != 1 {
+		t.Fatalf("policy.Sample called %d times for one Check+Write, want 1", policy.calls)
+	}
+	if got := rec.snapshot(); len(got) != 1 {
+		t.Fatalf("sink received %d records, want 1: %v", len(got), got)
+	}
+}
+
+func TestSampledTeeLoggerCheckDeniedNeverReachesSink(t *testing.T) {
+	policy := &countingPolicy{allow: false}
+	rec := &recordingLogger{}
+	log := SampledTeeLogger(policy, rec)
+
+	if cm := log.Check(InfoLevel, "hello"); cm.OK() {
+		t.Fatalf("Check(InfoLevel, ...).OK() = true, want false when the policy denies every sink")
+	}
+	if got := rec.snapshot(); len(got) != 0 {
+		t.Fatalf("sink received %d records, want 0: %v", len(got), got)
+	}
+}
+
+func TestSampledTeeLoggerDFatalBypassesPolicy(t *testing.T) {
+	policy := &countingPolicy{allow: false}
+	rec := &recordingLogger{}
+	log := SampledTeeLogger(policy, rec)
+
+	log.DFatal("escalate me")
+
+	if got := rec.dfatalSnapshot(); len(got) != 1 || got[0] != "escalate me" {
+		t.Fatalf("sub-logger DFatal calls = %v, want [\"escalate me\"]", got)
+	}
+	if got := rec.snapshot(); len(got) != 0 {
+		t.Fatalf("DFatal was delivered via Log instead of DFatal: %v", got)
+	}
+	if policy.calls != 0 {
+		t.Fatalf("policy.Sample called %d times for DFatal, want 0 (DFatal must bypass sampling like Panic/Fatal)", policy.calls)
+	}
+}
+
+func TestSampledTeeLoggerRateLimitedSurvivesCheckAndWrite(t *testing.T) {
+	// burst=1 means only the first Sample call for a given key should ever
+	// allow the record through; a Check+Write pair that samples twice would
+	// burn through the burst on Check alone and then (incorrectly) refuse
+	// to deliver on Write, or -- the bug this guards against -- Check and
+	// the real Log path could each consume one slot of a burst meant for a
+	// single logical call.
+	policy := RateLimited(time.Hour, 0, 1)
+	rec := &recordingLogger{}
+	log := SampledTeeLogger(policy, rec)
+
+	for i := 0; i < 3; i++ {
+		if cm := log.Check(InfoLevel, "hello"); cm.OK() {
+			cm.Write()
+		}
+	}
+
+	got := rec.snapshot()
+	if len(got) != 1 {
+		t.Fatalf("sink received %d records across 3 Check+Write pairs with burst=1, want 1: %v", len(got), got)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zapsinks provides additional zap.Logger implementations meant to
+// be used as sub-loggers of a zap.TeeLogger: a rotating file sink and a
+// syslog sink.
+package zapsinks
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RotateConfig configures NewRotatingFileLogger.
+type RotateConfig struct {
+	// Filename is the file to write to. Rotated copies are written
+	// alongside it, named after its base name and a timestamp.
+	Filename string
+	// MaxSizeMB is the size, in megabytes, Filename may reach before it's
+	// rotated. Defaults to 100 if zero.
+	MaxSizeMB int
+	// MaxAgeDays is how long rotated backups are kept before being removed.
+	// Zero means backups are never removed for age.
+	MaxAgeDays int
+	// MaxBackups is how many rotated backups are kept, oldest removed
+	// first. Zero means no limit.
+	MaxBackups int
+	// Compress gzips rotated backups.
+	Compress bool
+	// LocalTime uses the local timezone for the backup filename's
+	// timestamp suffix instead of UTC.
+	LocalTime bool
+}
+
+func (c RotateConfig) maxSizeBytes() int64 {
+	if c.MaxSizeMB > 0 {
+		return int64(c.MaxSizeMB) * 1024 * 1024
+	}
+	return 100 * 1024 * 1024
+}
+
+// NewRotatingFileLogger returns a zap.Logger that writes to cfg.Filename,
+// rotating it by size and pruning old backups by age and count. It plugs
+// directly into zap.TeeLogger alongside any other zap.Logger.
+func NewRotatingFileLogger(cfg RotateConfig) (zap.Logger, error) {
+	w := &rotatingWriter{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	base := zap.New(zap.NewJSONEncoder(), zap.Output(w))
+	return &rotatingLogger{Logger: base, w: w}, nil
+}
+
+// rotatingLogger wraps the Logger returned by zap.New so Check can report
+// the sink as unavailable while the underlying file couldn't be opened.
+type rotatingLogger struct {
+	zap.Logger
+	w *rotatingWriter
+}
+
+func (l *rotatingLogger) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
+	if !l.w.writable() {
+		return nil
+	}
+	return l.Logger.Check(lvl, msg)
+}
+
+func (l *rotatingLogger) With(fields ...zap.Field) zap.Logger {
+	return &rotatingLogger{Logger: l.Logger.With(fields...), w: l.w}
+}
+
+// rotatingWriter is an io.Writer (and zap.WriteSyncer) that rotates
+// cfg.Filename before a write would take it past cfg.MaxSizeMB.
+type rotatingWriter struct {
+	cfg RotateConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	err  error
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+	if w.size+int64(len(p)) > w.cfg.maxSizeBytes() {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	w.err = err
+	return n, err
+}
+
+func (w *rotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+func (w *rotatingWriter) writable() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file != nil && w.err == nil
+}
+
+func (w *rotatingWriter) open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.openLocked()
+}
+
+func (w *rotatingWriter) openLocked() error {
+	f, err := os.OpenFile(w.cfg.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		w.err = err
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		w.err = err
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	w.err = nil
+	return nil
+}
+
+func (w *rotatingWriter) rotateLocked() error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+
+	now := time.Now()
+	if !w.cfg.LocalTime {
+		now = now.UTC()
+	}
+	backupName := w.backupName(now)
+	if err := os.Rename(w.cfg.Filename, backupName); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if w.cfg.Compress {
+		go compressBackup(backupName)
+	}
+	go w.pruneBackups()
+
+	return w.openLocked()
+}
+
+func (w *rotatingWriter) backupName(t time.Time) string {
+	dir := filepath.Dir(w.cfg.Filename)
+	base := filepath.Base(w.cfg.Filename)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", name, t.Format("2006-01-02T15-04-05.000"), ext))
+}
+
+func compressBackup(name string) {
+	src, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer dst.Close()
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		gw.Close()
+		return
+	}
+	if err := gw.Close(); err != nil {
+		return
+	}
+	os.Remove(name)
+}
+
+// pruneBackups enforces MaxBackups and MaxAgeDays against the rotated
+// copies of cfg.Filename.
+func (w *rotatingWriter) pruneBackups() {
+	if w.cfg.MaxBackups <= 0 && w.cfg.MaxAgeDays <= 0 {
+		return
+	}
+
+	dir := filepath.Dir(w.cfg.Filename)
+	base := filepath.Base(w.cfg.Filename)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].modTime.After(backups[j].modTime) })
+
+	cutoff := time.Now().Add(-time.Duration(w.cfg.MaxAgeDays) * 24 * time.Hour)
+	for i, b := range backups {
+		tooMany := w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups
+		tooOld := w.cfg.MaxAgeDays > 0 && b.modTime.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapsinks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRotatingWriterRotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	cfg := RotateConfig{Filename: filepath.Join(dir, "test.log"), MaxSizeMB: 1}
+	w := &rotatingWriter{cfg: cfg}
+	if err := w.open(); err != nil {
+		t.Fatalf("open() = %v", err)
+	}
+
+	small := make([]byte, 10)
+	if _, err := w.Write(small); err != nil {
+		t.Fatalf("Write(small) = %v", err)
+	}
+	if w.size != int64(len(small)) {
+		t.Fatalf("size = %d, want %d", w.size, len(small))
+	}
+
+	big := make([]byte, cfg.maxSizeBytes())
+	if _, err := w.Write(big); err != nil {
+		t.Fatalf("Write(big) = %v", err)
+	}
+	if w.size != int64(len(big)) {
+		t.Fatalf("size after rotation = %d, want %d (rotation should reset to just the new write)", w.size, len(big))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir(%q) = %v", dir, err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("dir has %d entries, want 2 (active file + one rotated backup): %v", len(entries), entries)
+	}
+}
+
+func TestRotatingWriterPruneBackupsByCount(t *testing.T) {
+	dir := t.TempDir()
+	cfg := RotateConfig{Filename: filepath.Join(dir, "test.log"), MaxBackups: 2}
+	w := &rotatingWriter{cfg: cfg}
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		name := w.backupName(now.Add(time.Duration(i) * time.Second))
+		if err := os.WriteFile(name, []byte("x"), 0644); err != nil {
+			t.Fatalf("WriteFile(%q) = %v", name, err)
+		}
+	}
+
+	w.pruneBackups()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir(%q) = %v", dir, err)
+	}
+	if len(entries) != cfg.MaxBackups {
+		t.Fatalf("dir has %d backups after pruneBackups, want %d", len(entries), cfg.MaxBackups)
+	}
+}
+
+func TestRotatingLoggerCheckReflectsWritability(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewRotatingFileLogger(RotateConfig{Filename: filepath.Join(dir, "test.log")})
+	if err != nil {
+		t.Fatalf("NewRotatingFileLogger() = %v", err)
+	}
+	rl := logger.(*rotatingLogger)
+
+	if cm := rl.Check(zap.InfoLevel, "hello"); !cm.OK() {
+		t.Fatal("Check() = not OK while the file is open and writable")
+	}
+
+	rl.w.mu.Lock()
+	rl.w.err = os.ErrClosed
+	rl.w.mu.Unlock()
+
+	if cm := rl.Check(zap.InfoLevel, "hello"); cm.OK() {
+		t.Fatal("Check() = OK after the writer recorded an error, want not OK")
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapsinks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Facility is an RFC5424 syslog facility code.
+type Facility int
+
+// The standard syslog facilities.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// SyslogConfig configures NewSyslogLogger.
+type SyslogConfig struct {
+	// Network is one of "udp", "tcp", "tls", or "unix". Defaults to "udp".
+	Network string
+	// Address is a "host:port" for udp/tcp/tls, or a socket path for unix.
+	Address string
+	// Tag is the RFC5424 APP-NAME. Defaults to the process name.
+	Tag string
+	// Facility is the syslog facility fields are logged under. Defaults to
+	// FacilityLocal0; FacilityKern, the zero value, is replaced by that
+	// default.
+	Facility Facility
+	// EnterpriseID is the IANA enterprise number used in the structured
+	// data element name, e.g. "[fields@32473 ...]".
+	EnterpriseID string
+	// TLSConfig is used to dial when Network is "tls". A nil value uses
+	// crypto/tls's defaults.
+	TLSConfig *tls.Config
+}
+
+func (c SyslogConfig) tag() string {
+	if c.Tag != "" {
+		return c.Tag
+	}
+	return filepath.Base(os.Args[0])
+}
+
+func (c SyslogConfig) network() string {
+	if c.Network != "" {
+		return c.Network
+	}
+	return "udp"
+}
+
+func (c SyslogConfig) facility() Facility {
+	if c.Facility != FacilityKern {
+		return c.Facility
+	}
+	return FacilityLocal0
+}
+
+// NewSyslogLogger returns a zap.Logger that ships records to an RFC5424
+// syslog receiver over cfg.Network, encoding fields as a structured data
+// element rather than folding them into the message text.
+func NewSyslogLogger(cfg SyslogConfig) (zap.Logger, error) {
+	w := &syslogWriter{cfg: cfg}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	enc := &sdEncoder{tag: cfg.tag(), enterpriseID: cfg.EnterpriseID, facility: cfg.facility()}
+	base := zap.New(enc, zap.Output(w))
+	return &syslogLogger{Logger: base, w: w}, nil
+}
+
+// syslogLogger wraps the Logger returned by zap.New so Check can report the
+// sink as unavailable while the syslog socket is down.
+type syslogLogger struct {
+	zap.Logger
+	w *syslogWriter
+}
+
+func (l *syslogLogger) Check(lvl zap.Level, msg string) *zap.CheckedMessage {
+	if !l.w.writable() {
+		return nil
+	}
+	return l.Logger.Check(lvl, msg)
+}
+
+func (l *syslogLogger) With(fields ...zap.Field) zap.Logger {
+	return &syslogLogger{Logger: l.Logger.With(fields...), w: l.w}
+}
+
+// syslogWriter is an io.Writer (and zap.WriteSyncer) backed by a syslog
+// connection. It redials lazily, on the next Write, if the connection
+// drops.
+type syslogWriter struct {
+	cfg SyslogConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		if err := w.dialLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.conn.Write(p)
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
+
+func (w *syslogWriter) Sync() error { return nil }
+
+func (w *syslogWriter) writable() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn != nil
+}
+
+func (w *syslogWriter) dial() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dialLocked()
+}
+
+func (w *syslogWriter) dialLocked() error {
+	if w.cfg.network() == "tls" {
+		conn, err := tls.Dial("tcp", w.cfg.Address, w.cfg.TLSConfig)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+	conn, err := net.Dial(w.cfg.network(), w.cfg.Address)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// sdEncoder is a zap.Encoder that renders each record as a single RFC5424
+// line, with Fields rendered as one [fields@enterprise-id ...] structured
+// data element instead of being folded into the message.
+type sdEncoder struct {
+	tag          string
+	enterpriseID string
+	facility     Facility
+	// prefix is prepended to every key this encoder writes, so a nested
+	// AddMarshaler can namespace its fields under the parent key instead
+	// of colliding with the parent's own field names.
+	prefix string
+
+	sd bytes.Buffer
+}
+
+func (e *sdEncoder) Clone() zap.Encoder {
+	clone := &sdEncoder{tag: e.tag, enterpriseID: e.enterpriseID, facility: e.facility, prefix: e.prefix}
+	clone.sd.Write(e.sd.Bytes())
+	return clone
+}
+
+// Free is a no-op: sdEncoder holds no pooled resources to release.
+func (e *sdEncoder) Free() {}
+
+func (e *sdEncoder) AddBool(key string, val bool)       { e.addSD(key, fmt.Sprintf("%t", val)) }
+func (e *sdEncoder) AddFloat64(key string, val float64) { e.addSD(key, fmt.Sprintf("%v", val)) }
+func (e *sdEncoder) AddInt(key string, val int)         { e.addSD(key, fmt.Sprintf("%d", val)) }
+func (e *sdEncoder) AddInt64(key string, val int64)     { e.addSD(key, fmt.Sprintf("%d", val)) }
+func (e *sdEncoder) AddUint(key string, val uint)       { e.addSD(key, fmt.Sprintf("%d", val)) }
+func (e *sdEncoder) AddUint64(key string, val uint64)   { e.addSD(key, fmt.Sprintf("%d", val)) }
+func (e *sdEncoder) AddUintptr(key string, val uintptr) { e.addSD(key, fmt.Sprintf("%d", val)) }
+func (e *sdEncoder) AddString(key, val string)          { e.addSD(key, val) }
+
+func (e *sdEncoder) AddObject(key string, val interface{}) error {
+	e.addSD(key, fmt.Sprintf("%v", val))
+	return nil
+}
+
+func (e *sdEncoder) AddMarshaler(key string, m zap.LogMarshaler) error {
+	enc := &sdEncoder{tag: e.tag, enterpriseID: e.enterpriseID, facility: e.facility, prefix: e.prefix + key + "."}
+	if err := m.MarshalLog(enc); err != nil {
+		return err
+	}
+	e.sd.Write(enc.sd.Bytes())
+	return nil
+}
+
+func (e *sdEncoder) addSD(key, val string) {
+	fmt.Fprintf(&e.sd, ` %s="%s"`, e.prefix+key, escapeSD(val))
+}
+
+func escapeSD(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+// escapeMsg escapes, as literal \n and \r, the control characters
+// WriteEntry's line format can't tolerate in the message text: a bare \n or \r would let the message
+// forge a second syslog record or inject fields of its own.
+func escapeMsg(s string) string {
+	r := strings.NewReplacer("\n", `\n`, "\r", `\r`)
+	return r.Replace(s)
+}
+
+func (e *sdEncoder) WriteEntry(sink io.Writer, msg string, lvl zap.Level, t time.Time) error {
+	pri := int(e.facility)*8 + severity(lvl)
+	host, err := os.Hostname()
+	if err != nil {
+		host = "-"
+	}
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - [fields@%s%s] %s\n",
+		pri, t.UTC().Format(time.RFC3339), host, e.tag, os.Getpid(), e.enterpriseID, e.sd.String(), escapeMsg(msg))
+	_, err = sink.Write([]byte(line))
+	return err
+}
+
+// severity maps a zap.Level to its RFC5424 severity.
+func severity(lvl zap.Level) int {
+	switch lvl {
+	case zap.DebugLevel:
+		return 7
+	case zap.InfoLevel:
+		return 6
+	case zap.WarnLevel:
+		return 4
+	case zap.ErrorLevel:
+		return 3
+	case zap.PanicLevel:
+		return 1
+	case zap.FatalLevel:
+		return 0
+	default:
+		return 6
+	}
+}
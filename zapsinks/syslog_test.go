@@ -0,0 +1,167 @@
+// Copyright (c) 2016 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zapsinks
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestNewSyslogLoggerFailsWhenUnreachable(t *testing.T) {
+	_, err := NewSyslogLogger(SyslogConfig{Network: "unix", Address: filepath.Join(t.TempDir(), "does-not-exist.sock")})
+	if err == nil {
+		t.Fatal("NewSyslogLogger() = nil error, want one for an address nothing is listening on")
+	}
+}
+
+func TestSyslogLoggerCheckReflectsConnection(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("net.Listen(%q) = %v", sock, err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go discardConn(conn)
+		}
+	}()
+
+	logger, err := NewSyslogLogger(SyslogConfig{Network: "unix", Address: sock})
+	if err != nil {
+		t.Fatalf("NewSyslogLogger() = %v", err)
+	}
+	sl := logger.(*syslogLogger)
+
+	if cm := sl.Check(zap.InfoLevel, "hello"); !cm.OK() {
+		t.Fatal("Check() = not OK right after a successful dial")
+	}
+
+	sl.w.mu.Lock()
+	sl.w.conn.Close()
+	sl.w.conn = nil
+	sl.w.mu.Unlock()
+
+	if cm := sl.Check(zap.InfoLevel, "hello"); cm.OK() {
+		t.Fatal("Check() = OK after the connection was dropped, want not OK until the next successful Write redials")
+	}
+}
+
+func discardConn(conn net.Conn) {
+	buf := make([]byte, 4096)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+func TestEscapeSDEscapesBackslashQuoteAndBracket(t *testing.T) {
+	got := escapeSD(`back\slash "quoted" [bracket]`)
+	want := `back\\slash \"quoted\" [bracket\]`
+	if got != want {
+		t.Fatalf("escapeSD(...) = %q, want %q", got, want)
+	}
+}
+
+func TestSeverityMapsEveryLevel(t *testing.T) {
+	cases := []struct {
+		lvl  zap.Level
+		want int
+	}{
+		{zap.DebugLevel, 7},
+		{zap.InfoLevel, 6},
+		{zap.WarnLevel, 4},
+		{zap.ErrorLevel, 3},
+		{zap.PanicLevel, 1},
+		{zap.FatalLevel, 0},
+	}
+	for _, c := range cases {
+		if got := severity(c.lvl); got != c.want {
+			t.Errorf("severity(%v) = %d, want %d", c.lvl, got, c.want)
+		}
+	}
+}
+
+func TestSDEncoderAddStringRendersStructuredData(t *testing.T) {
+	e := &sdEncoder{tag: "myapp", enterpriseID: "32473"}
+	e.AddString("user", "alice")
+	e.AddInt("attempt", 3)
+
+	if got, want := e.sd.String(), ` user="alice" attempt="3"`; got != want {
+		t.Fatalf("sd buffer = %q, want %q", got, want)
+	}
+}
+
+func TestSDEncoderAddMarshalerNestsFieldsUnderKey(t *testing.T) {
+	e := &sdEncoder{tag: "myapp"}
+	e.AddString("id", "order-1")
+	e.AddMarshaler("user", zap.LogMarshalerFunc(func(kv zap.KeyValue) error {
+		kv.AddString("id", "alice")
+		return nil
+	}))
+
+	if got, want := e.sd.String(), ` id="order-1" user.id="alice"`; got != want {
+		t.Fatalf("sd buffer = %q, want %q (nested field must be namespaced under the marshaler's key)", got, want)
+	}
+}
+
+func TestWriteEntryEscapesNewlinesInMessage(t *testing.T) {
+	e := &sdEncoder{tag: "myapp"}
+	var buf bytes.Buffer
+
+	if err := e.WriteEntry(&buf, "line one\nLINE TWO\r\nLINE THREE", zap.InfoLevel, time.Now()); err != nil {
+		t.Fatalf("WriteEntry() = %v", err)
+	}
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("WriteEntry() output = %q, want exactly one newline (the line terminator), not one injected by the message", line)
+	}
+	if !strings.Contains(line, `line one\nLINE TWO\r\nLINE THREE`) {
+		t.Fatalf("WriteEntry() output = %q, want the message's control characters escaped in place", line)
+	}
+}
+
+func TestSDEncoderCloneIsIndependent(t *testing.T) {
+	e := &sdEncoder{tag: "myapp"}
+	e.AddString("a", "1")
+
+	clone := e.Clone().(*sdEncoder)
+	clone.AddString("b", "2")
+
+	if e.sd.String() == clone.sd.String() {
+		t.Fatal("Clone() shares state with the original encoder, want an independent copy")
+	}
+	if got, want := e.sd.String(), ` a="1"`; got != want {
+		t.Fatalf("original sd buffer = %q, want %q (mutated by the clone)", got, want)
+	}
+}